@@ -1,19 +1,30 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Configuration holds application configuration
@@ -23,6 +34,24 @@ type Configuration struct {
 	LLMModelName string
 	LogLevel    string
 	Version     string
+
+	SessionBackend  string
+	SessionCapacity int
+	SessionTTL      time.Duration
+
+	LLMProvider string
+
+	OllamaBaseURL string
+	OllamaModel   string
+
+	AnthropicBaseURL string
+	AnthropicAPIKey  string
+	AnthropicModel   string
+	AnthropicVersion string
+
+	RateLimitRPS   float64
+	RateLimitBurst float64
+	TrustedProxies []*net.IPNet
 }
 
 // Cache implementation
@@ -40,6 +69,7 @@ type cacheItem struct {
 type ChatRequest struct {
 	Model    string        `json:"model"`
 	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
 }
 
 // ChatMessage represents a message in the chat
@@ -58,27 +88,103 @@ type ChatResponse struct {
 		Message      ChatMessage `json:"message"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// ChatStreamChunk represents a single SSE/NDJSON frame emitted by an
+// OpenAI-compatible streaming completion ("choices[0].delta.content").
+type ChatStreamChunk struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
 }
 
 // Global variables
 var (
-	config Configuration
-	cache  = &Cache{items: make(map[string]cacheItem)}
-	logger *log.Logger
-	startTime = time.Now()
+	config       Configuration
+	cache        = &Cache{items: make(map[string]cacheItem)}
+	logger       *log.Logger
+	slogLogger   *slog.Logger
+	startTime    = time.Now()
+	sessionStore SessionStore
+	llmProvider  Provider
+)
+
+// defaultSystemPrompt is sent as the system message on every LLM call.
+const defaultSystemPrompt = "You are a helpful assistant. Please provide structured responses using markdown formatting. Use headers (# for main points), bullet points (- for lists), bold (**text**) for emphasis, and code blocks (```code```) for code examples. Organize your responses with clear sections and concise explanations."
+
+// Prometheus metrics
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hellogenai_http_requests_total",
+		Help: "Total number of HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hellogenai_http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+
+	llmRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hellogenai_llm_request_duration_seconds",
+		Help:    "Upstream LLM call latency in seconds, by provider, model and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "model", "outcome"})
+
+	llmTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hellogenai_llm_tokens_total",
+		Help: "Total tokens exchanged with the LLM, by direction (prompt/completion).",
+	}, []string{"direction"})
+
+	cacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hellogenai_cache_hits_total",
+		Help: "Total number of response cache hits.",
+	})
+
+	cacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hellogenai_cache_misses_total",
+		Help: "Total number of response cache misses.",
+	})
+
+	rateLimitedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hellogenai_rate_limited_total",
+		Help: "Total number of requests rejected by the rate limiter.",
+	})
 )
 
 // Initialize the application
 func init() {
 	// Configure logger
 	logger = log.New(os.Stdout, "[hello-genai] ", log.LstdFlags)
+	slogLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	// Load configuration
 	config = loadConfig()
-	
+
 	// Log configuration
-	logger.Printf("Configuration loaded: Port=%s, LLM Base URL=%s, Model=%s", 
+	logger.Printf("Configuration loaded: Port=%s, LLM Base URL=%s, Model=%s",
 		config.Port, config.LLMBaseURL, config.LLMModelName)
+
+	// Set up the conversation session store
+	sessionStore = newSessionStore(config)
+
+	// Select the LLM backend
+	llmProvider = newProvider(config)
+	logger.Printf("Using LLM provider: %s (model=%s)", config.LLMProvider, llmProvider.ModelName())
+
+	// Set up the rate limiter
+	rateLimiter = NewRateLimiter(config.RateLimitRPS, config.RateLimitBurst, 10*time.Minute)
 }
 
 // loadConfig loads configuration from environment variables with defaults
@@ -91,13 +197,49 @@ func loadConfig() Configuration {
 	// Use Docker Model Runner injected variables
 	llamaURL := os.Getenv("LLAMA_URL")
 	llamaModel := os.Getenv("LLAMA_MODEL")
-	
-	if llamaURL == "" {
-		logger.Println("WARNING: No LLM endpoint configured. Set LLAMA_URL.")
+
+	llmProvider := os.Getenv("LLM_PROVIDER")
+	if llmProvider == "" {
+		llmProvider = "openai"
 	}
 
-	if llamaModel == "" {
-		logger.Println("WARNING: No LLM model configured. Set LLAMA_MODEL.")
+	switch llmProvider {
+	case "openai":
+		if llamaURL == "" {
+			logger.Println("WARNING: No LLM endpoint configured. Set LLAMA_URL.")
+		}
+		if llamaModel == "" {
+			logger.Println("WARNING: No LLM model configured. Set LLAMA_MODEL.")
+		}
+	case "ollama":
+		if os.Getenv("OLLAMA_URL") == "" {
+			logger.Println("WARNING: No Ollama endpoint configured. Set OLLAMA_URL.")
+		}
+		if os.Getenv("OLLAMA_MODEL") == "" {
+			logger.Println("WARNING: No Ollama model configured. Set OLLAMA_MODEL.")
+		}
+	case "anthropic":
+		if os.Getenv("ANTHROPIC_API_KEY") == "" {
+			logger.Println("WARNING: No Anthropic API key configured. Set ANTHROPIC_API_KEY.")
+		}
+		if os.Getenv("ANTHROPIC_MODEL") == "" {
+			logger.Println("WARNING: No Anthropic model configured. Set ANTHROPIC_MODEL.")
+		}
+	}
+
+	ollamaBaseURL := os.Getenv("OLLAMA_URL")
+	if ollamaBaseURL == "" {
+		ollamaBaseURL = "http://localhost:11434"
+	}
+
+	anthropicBaseURL := os.Getenv("ANTHROPIC_BASE_URL")
+	if anthropicBaseURL == "" {
+		anthropicBaseURL = "https://api.anthropic.com"
+	}
+
+	anthropicVersion := os.Getenv("ANTHROPIC_VERSION")
+	if anthropicVersion == "" {
+		anthropicVersion = "2023-06-01"
 	}
 
 	logLevel := os.Getenv("LOG_LEVEL")
@@ -105,23 +247,67 @@ func loadConfig() Configuration {
 		logLevel = "INFO"
 	}
 
+	sessionBackend := os.Getenv("SESSION_BACKEND")
+	if sessionBackend == "" {
+		sessionBackend = "memory"
+	}
+
+	sessionCapacity := defaultSessionCapacity
+	if v := os.Getenv("SESSION_CAPACITY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			sessionCapacity = n
+		}
+	}
+
+	sessionTTL := defaultSessionTTL
+	if v := os.Getenv("SESSION_TTL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			sessionTTL = time.Duration(n) * time.Minute
+		}
+	}
+
+	// Defaults preserve the historical 10 requests/minute.
+	rateLimitRPS := 10.0 / 60.0
+	if v := os.Getenv("RATE_LIMIT_RPS"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil && n > 0 {
+			rateLimitRPS = n
+		}
+	}
+
+	rateLimitBurst := 10.0
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil && n > 0 {
+			rateLimitBurst = n
+		}
+	}
+
+	trustedProxies := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
 	return Configuration{
 		Port:        port,
 		LLMBaseURL:  llamaURL,
 		LLMModelName: llamaModel,
 		LogLevel:    logLevel,
 		Version:     "1.0.0",
-	}
-}
 
-// getLLMEndpoint returns the complete LLM API endpoint URL
-func getLLMEndpoint() string {
-	return config.LLMBaseURL + "/chat/completions"
-}
+		SessionBackend:  sessionBackend,
+		SessionCapacity: sessionCapacity,
+		SessionTTL:      sessionTTL,
+
+		LLMProvider: llmProvider,
+
+		OllamaBaseURL: ollamaBaseURL,
+		OllamaModel:   os.Getenv("OLLAMA_MODEL"),
 
-// getModelName returns the model name to use for API requests
-func getModelName() string {
-	return config.LLMModelName
+		AnthropicBaseURL: anthropicBaseURL,
+		AnthropicAPIKey:  os.Getenv("ANTHROPIC_API_KEY"),
+		AnthropicModel:   os.Getenv("ANTHROPIC_MODEL"),
+		AnthropicVersion: anthropicVersion,
+
+		RateLimitRPS:   rateLimitRPS,
+		RateLimitBurst: rateLimitBurst,
+		TrustedProxies: trustedProxies,
+	}
 }
 
 // Cache methods
@@ -153,50 +339,353 @@ func (c *Cache) Set(key, value string, ttl time.Duration) {
 	}
 }
 
-// Rate limiter implementation
+// Conversation sessions
+//
+// A session holds the message history for one back-and-forth with the
+// model so the Provider gets real context instead of a single isolated
+// message on every request.
+const (
+	defaultSessionCapacity = 1000
+	defaultSessionTTL      = 60 * time.Minute
+	defaultHistoryLimit    = 20
+	maxHistoryLimit        = 100
+	// sessionCharBudget bounds how many characters of history are sent
+	// upstream; older turns are dropped first once a conversation exceeds it.
+	sessionCharBudget = 8000
+)
+
+// SessionStore persists per-conversation message history. The only
+// implementation is in-memory; SESSION_BACKEND accepts no other backend.
+type SessionStore interface {
+	Get(id string) ([]ChatMessage, bool)
+	Append(id string, messages ...ChatMessage)
+	Delete(id string)
+}
+
+// newSessionStore builds the SessionStore selected by cfg.SessionBackend.
+// Only the in-memory backend ships today; any other value (e.g. "sqlite",
+// "bolt") is a fatal startup error rather than a silent fallback.
+func newSessionStore(cfg Configuration) SessionStore {
+	switch cfg.SessionBackend {
+	case "", "memory":
+		return NewMemorySessionStore(cfg.SessionCapacity, cfg.SessionTTL)
+	default:
+		// Only the in-memory backend ships today; failing fast here beats
+		// silently degrading to it, since that would drop history on
+		// restart without the operator ever noticing SESSION_BACKEND was
+		// ignored.
+		logger.Fatalf("SESSION_BACKEND=%s is not a supported backend (supported: memory)", cfg.SessionBackend)
+		return nil
+	}
+}
+
+// MemorySessionStore is a SessionStore backed by a map, with LRU eviction
+// once capacity is reached and a TTL that expires idle sessions.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*list.Element
+	order    *list.List
+	capacity int
+	ttl      time.Duration
+}
+
+type sessionRecord struct {
+	id      string
+	history []ChatMessage
+	expires time.Time
+}
+
+// NewMemorySessionStore creates a MemorySessionStore holding at most
+// capacity sessions, each expiring ttl after its last activity.
+func NewMemorySessionStore(capacity int, ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*list.Element),
+		order:    list.New(),
+		capacity: capacity,
+		ttl:      ttl,
+	}
+}
+
+func (s *MemorySessionStore) Get(id string) ([]ChatMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, found := s.sessions[id]
+	if !found {
+		return nil, false
+	}
+
+	rec := el.Value.(*sessionRecord)
+	if time.Now().After(rec.expires) {
+		s.order.Remove(el)
+		delete(s.sessions, id)
+		return nil, false
+	}
+
+	s.order.MoveToFront(el)
+	history := make([]ChatMessage, len(rec.history))
+	copy(history, rec.history)
+	return history, true
+}
+
+func (s *MemorySessionStore) Append(id string, messages ...ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, found := s.sessions[id]
+	var rec *sessionRecord
+	if found {
+		rec = el.Value.(*sessionRecord)
+		s.order.MoveToFront(el)
+	} else {
+		rec = &sessionRecord{id: id}
+		s.sessions[id] = s.order.PushFront(rec)
+		s.evictOverCapacity()
+	}
+
+	rec.history = append(rec.history, messages...)
+	rec.expires = time.Now().Add(s.ttl)
+}
+
+func (s *MemorySessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, found := s.sessions[id]; found {
+		s.order.Remove(el)
+		delete(s.sessions, id)
+	}
+}
+
+// evictOverCapacity drops the least-recently-used session(s) once the store
+// grows past capacity. Callers must hold s.mu.
+func (s *MemorySessionStore) evictOverCapacity() {
+	for len(s.sessions) > s.capacity {
+		oldest := s.order.Back()
+		if oldest == nil {
+			return
+		}
+		rec := oldest.Value.(*sessionRecord)
+		s.order.Remove(oldest)
+		delete(s.sessions, rec.id)
+	}
+}
+
+// trimHistory drops the oldest turns until history fits within both
+// historyLimit messages and sessionCharBudget characters, keeping the
+// upstream request bounded no matter how long the conversation runs.
+func trimHistory(history []ChatMessage, historyLimit int) []ChatMessage {
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+
+	total := 0
+	for _, m := range history {
+		total += len(m.Content)
+	}
+	for total > sessionCharBudget && len(history) > 0 {
+		total -= len(history[0].Content)
+		history = history[1:]
+	}
+	return history
+}
+
+// newSessionID returns a random v4 UUID used to identify a conversation.
+func newSessionID() string {
+	return newUUID()
+}
+
+// newUUID returns a random v4 UUID, used to identify sessions and requests.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// The CSPRNG should never fail in practice; fall back to a
+		// time-based ID rather than leaving the caller unidentified.
+		return fmt.Sprintf("id-%x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Rate limiter implementation: a per-client token bucket. Each client starts
+// with a full bucket of `burst` tokens that refills at `rate` tokens/sec, so
+// short bursts are allowed while sustained traffic is smoothed to `rate`.
 type RateLimiter struct {
-	clients map[string][]time.Time
 	mu      sync.Mutex
-	limit   int
-	window  time.Duration
+	clients map[string]*tokenBucket
+	rate    float64
+	burst   float64
+	idleTTL time.Duration
+	stop    chan struct{}
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+	lastSeen   time.Time
 }
 
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	return &RateLimiter{
-		clients: make(map[string][]time.Time),
-		limit:   limit,
-		window:  window,
+// NewRateLimiter creates a limiter allowing `rate` requests/sec per client,
+// with bursts up to `burst` requests, and starts a janitor goroutine that
+// evicts buckets idle for longer than idleTTL so clients map doesn't grow
+// unboundedly.
+func NewRateLimiter(rate, burst float64, idleTTL time.Duration) *RateLimiter {
+	rl := &RateLimiter{
+		clients: make(map[string]*tokenBucket),
+		rate:    rate,
+		burst:   burst,
+		idleTTL: idleTTL,
+		stop:    make(chan struct{}),
 	}
+	go rl.janitor()
+	return rl
 }
 
-func (rl *RateLimiter) Allow(clientIP string) bool {
+// Allow reports whether clientKey may proceed, the tokens remaining in its
+// bucket, and (when denied) how long the client should wait before retrying.
+func (rl *RateLimiter) Allow(clientKey string) (allowed bool, remaining float64, retryAfter time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	
+
 	now := time.Now()
-	
-	// Remove timestamps outside the window
-	var validTimestamps []time.Time
-	for _, ts := range rl.clients[clientIP] {
-		if now.Sub(ts) <= rl.window {
-			validTimestamps = append(validTimestamps, ts)
+	b, found := rl.clients[clientKey]
+	if !found {
+		b = &tokenBucket{tokens: rl.burst, lastRefill: now}
+		rl.clients[clientKey] = b
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = minFloat(rl.burst, b.tokens+elapsed*rl.rate)
+	b.lastRefill = now
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		retryAfter = time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+		return false, b.tokens, retryAfter
+	}
+
+	b.tokens--
+	return true, b.tokens, 0
+}
+
+// ActiveClients returns the number of clients with a live bucket, for
+// surfacing in /health.
+func (rl *RateLimiter) ActiveClients() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.clients)
+}
+
+// janitor periodically evicts buckets that have been idle past idleTTL.
+func (rl *RateLimiter) janitor() {
+	ticker := time.NewTicker(rl.idleTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rl.mu.Lock()
+			now := time.Now()
+			for key, b := range rl.clients {
+				if now.Sub(b.lastSeen) > rl.idleTTL {
+					delete(rl.clients, key)
+				}
+			}
+			rl.mu.Unlock()
+		case <-rl.stop:
+			return
 		}
 	}
-	
-	rl.clients[clientIP] = validTimestamps
-	
-	// Check if client has reached the limit
-	if len(validTimestamps) >= rl.limit {
-		return false
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
 	}
-	
-	// Add current timestamp
-	rl.clients[clientIP] = append(rl.clients[clientIP], now)
-	return true
+	return b
+}
+
+// rateLimiter is built in init() once config is loaded; defaults preserve
+// the historical ~10 requests/minute, now smoothed as a token bucket
+// instead of a sliding window.
+var rateLimiter *RateLimiter
+
+// clientKeyFor derives the rate-limiting key for a request. Behind a
+// reverse proxy, r.RemoteAddr is the proxy's own address, so when that
+// address falls within TRUSTED_PROXIES we trust X-Forwarded-For/X-Real-IP
+// instead; otherwise those headers are attacker-controlled and ignored.
+func clientKeyFor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remoteIP := net.ParseIP(host)
+	if remoteIP == nil || !isTrustedProxy(remoteIP, config.TrustedProxies) {
+		return host
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		if client := firstUntrustedHop(xff); client != "" {
+			return client
+		}
+	}
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return strings.TrimSpace(xri)
+	}
+	return host
+}
+
+// firstUntrustedHop walks an X-Forwarded-For chain right-to-left, skipping
+// entries inside TRUSTED_PROXIES, and returns the first (i.e. closest to the
+// client) hop that isn't a trusted proxy. Proxies like nginx append the real
+// client IP to the end of any client-supplied XFF, so reading the leftmost
+// entry unconditionally would let a client mint a fresh rate-limit key per
+// request simply by sending its own X-Forwarded-For.
+func firstUntrustedHop(xff string) string {
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip := net.ParseIP(hop)
+		if ip == nil || !isTrustedProxy(ip, config.TrustedProxies) {
+			return hop
+		}
+	}
+	return ""
+}
+
+// parseTrustedProxies parses a comma-separated list of CIDR ranges from
+// TRUSTED_PROXIES, skipping and logging any entry that doesn't parse.
+func parseTrustedProxies(raw string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			logger.Printf("WARNING: invalid TRUSTED_PROXIES entry %q: %v", entry, err)
+			continue
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets
 }
 
-// Create a rate limiter: 10 requests per minute
-var rateLimiter = NewRateLimiter(10, time.Minute)
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
 
 // Middleware for adding security headers
 func securityHeadersMiddleware(next http.Handler) http.Handler {
@@ -209,23 +698,105 @@ func securityHeadersMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Middleware for logging requests
+// requestIDContextKey is the context key requestIDMiddleware stores the
+// per-request ID under.
+type requestIDContextKey struct{}
+
+// requestIDMiddleware assigns each request a UUID, echoes it back as
+// X-Request-ID, and makes it available to downstream handlers/middleware
+// via the request context.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := newUUID()
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDContextKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// statusRecorder wraps a ResponseWriter to capture the status code written,
+// which net/http doesn't otherwise expose to middleware.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush forwards to the underlying ResponseWriter's Flush, if any, so that
+// statusRecorder still satisfies http.Flusher for streaming handlers.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying ResponseWriter for http.ResponseController
+// and other callers that use errors.As-style unwrapping.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// unmatchedRouteLabel is used in place of the raw request path for requests
+// that don't match any registered route (404s, scanners). Echoing
+// r.URL.Path directly would let a caller mint unbounded Prometheus label
+// sets just by hitting distinct junk paths.
+const unmatchedRouteLabel = "<unmatched>"
+
+// routeTemplate returns the matched mux route pattern (e.g.
+// "/api/sessions/{id}") so metrics/logs group by endpoint shape rather than
+// by every distinct path.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRouteLabel
+}
+
+// Middleware for logging requests: records Prometheus metrics and emits a
+// structured JSON log line with latency, status and request ID.
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		logger.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		latency := time.Since(start)
+
+		route := routeTemplate(r)
+		status := strconv.Itoa(rec.status)
+		httpRequestsTotal.WithLabelValues(r.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(route).Observe(latency.Seconds())
+
+		slogLogger.Info("http_request",
+			"method", r.Method,
+			"path", r.RequestURI,
+			"route", route,
+			"status", rec.status,
+			"latency_ms", float64(latency.Microseconds())/1000.0,
+			"request_id", requestIDFromContext(r.Context()),
+		)
 	})
 }
 
 func main() {
 	// Create a new router
 	router := mux.NewRouter()
-	
+
 	// Apply middleware
+	router.Use(requestIDMiddleware)
 	router.Use(securityHeadersMiddleware)
 	router.Use(loggingMiddleware)
-	
+
 	// Static file server
 	fs := http.FileServer(http.Dir("./static"))
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
@@ -254,7 +825,11 @@ func main() {
 	
 	// API routes
 	router.HandleFunc("/api/chat", handleChatAPI).Methods("POST")
+	router.HandleFunc("/api/sessions/{id}", handleGetSession).Methods("GET")
+	router.HandleFunc("/api/sessions/{id}", handleDeleteSession).Methods("DELETE")
+	router.HandleFunc("/api/prompt-starters", handlePromptStarters).Methods("POST")
 	router.HandleFunc("/health", handleHealthCheck).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	router.HandleFunc("/example", handleExample).Methods("GET")
 	
 	// Swagger UI
@@ -283,8 +858,7 @@ func main() {
 	// Start the server
 	serverAddr := ":" + config.Port
 	logger.Printf("Server starting on http://localhost%s", serverAddr)
-	logger.Printf("Using LLM endpoint: %s", getLLMEndpoint())
-	logger.Printf("Using model: %s", getModelName())
+	logger.Printf("Using LLM provider: %s, model: %s", config.LLMProvider, llmProvider.ModelName())
 	
 	// Configure server with timeouts
 	server := &http.Server{
@@ -321,7 +895,7 @@ func handleExample(w http.ResponseWriter, r *http.Request) {
 func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Check if LLM API is accessible
 	llmStatus := "ok"
-	if config.LLMBaseURL == "" {
+	if llmProvider.ModelName() == "" {
 		llmStatus = "not_configured"
 	}
 	
@@ -347,6 +921,11 @@ func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 		},
 		"go_version": runtime.Version(),
 		"goroutines": runtime.NumGoroutine(),
+		"rate_limiter": map[string]interface{}{
+			"rate_rps":       config.RateLimitRPS,
+			"burst":          config.RateLimitBurst,
+			"active_clients": rateLimiter.ActiveClients(),
+		},
 	}
 	
 	// Set the content type header
@@ -377,12 +956,17 @@ func validateChatRequest(data map[string]interface{}) (bool, string) {
 // handleChatAPI processes chat API requests
 func handleChatAPI(w http.ResponseWriter, r *http.Request) {
 	// Apply rate limiting
-	clientIP := r.RemoteAddr
-	if !rateLimiter.Allow(clientIP) {
+	allowed, remaining, retryAfter := rateLimiter.Allow(clientKeyFor(r))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(retryAfter).Unix(), 10))
+		rateLimitedTotal.Inc()
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
-	
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining)))
+
 	// Parse the request body
 	var requestBody map[string]interface{}
 	err := json.NewDecoder(r.Body).Decode(&requestBody)
@@ -399,110 +983,825 @@ func handleChatAPI(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	message := result
-	
+
 	// Special command for getting model info
 	if message == "!modelinfo" {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
-			"model": getModelName(),
+			"model": llmProvider.ModelName(),
 		})
 		return
 	}
-	
+
+	sessionID, isNewSession := sessionIDFrom(requestBody)
+	historyLimit := historyLimitFrom(requestBody)
+	history := trimHistory(historyFor(sessionID, isNewSession), historyLimit)
+	messages := buildMessages(history, message)
+
+	cacheKey := cacheKeyFor(sessionID, message, history)
+
+	// Streaming is requested either via the JSON body ("stream": true) or by
+	// the client advertising it in Accept. Streamed responses bypass the
+	// cache lookup (we only have a partial answer until the stream ends) but
+	// still write back under the same key as the non-streamed path, so a
+	// cached answer is reusable regardless of which path produced it.
+	if wantsStream(requestBody, r) {
+		handleChatStream(w, r, sessionID, message, cacheKey, messages)
+		return
+	}
+
 	// Check cache first
-	if cachedResponse, found := cache.Get(message); found {
+	if cachedResponse, found := cache.Get(cacheKey); found {
+		cacheHitsTotal.Inc()
 		logger.Println("Cache hit for message")
+		sessionStore.Append(sessionID, ChatMessage{Role: "user", Content: message}, ChatMessage{Role: "assistant", Content: cachedResponse})
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
-			"response": cachedResponse,
+			"response":   cachedResponse,
+			"session_id": sessionID,
 		})
 		return
 	}
-	
+	cacheMissesTotal.Inc()
+
 	// Call the LLM API
-	response, err := callLLMAPI(message)
+	llmStart := time.Now()
+	response, err := llmProvider.Chat(r.Context(), messages)
+	observeLLMCall(llmStart, err)
 	if err != nil {
 		logger.Printf("Error calling LLM API: %v", err)
 		http.Error(w, "Failed to get response from LLM", http.StatusInternalServerError)
 		return
 	}
-	
-	// Cache the response (5 minutes TTL)
-	cache.Set(message, response, 5*time.Minute)
-	
+
+	// Cache the response (5 minutes TTL) and persist the turn
+	cache.Set(cacheKey, response, 5*time.Minute)
+	sessionStore.Append(sessionID, ChatMessage{Role: "user", Content: message}, ChatMessage{Role: "assistant", Content: response})
+
 	// Return the response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"response": response,
+		"response":   response,
+		"session_id": sessionID,
 	})
 }
 
-// callLLMAPI calls the LLM API and returns the response
-func callLLMAPI(userMessage string) (string, error) {
-	// Prepare the request body
-	chatRequest := ChatRequest{
-		Model: getModelName(),
-		Messages: []ChatMessage{
-			{
-				Role:    "system",
-				Content: "You are a helpful assistant. Please provide structured responses using markdown formatting. Use headers (# for main points), bullet points (- for lists), bold (**text**) for emphasis, and code blocks (```code```) for code examples. Organize your responses with clear sections and concise explanations.",
-			},
-			{
-				Role:    "user",
-				Content: userMessage,
-			},
-		},
+// sessionIDFrom reads session_id from the request body, generating a new
+// one when absent so the response can always carry an ID back to the caller.
+func sessionIDFrom(requestBody map[string]interface{}) (id string, isNew bool) {
+	if id, ok := requestBody["session_id"].(string); ok && id != "" {
+		return id, false
 	}
+	return newSessionID(), true
+}
 
-	requestBody, err := json.Marshal(chatRequest)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+// historyLimitFrom reads history_limit from the request body, falling back
+// to defaultHistoryLimit when absent or out of bounds.
+func historyLimitFrom(requestBody map[string]interface{}) int {
+	if v, ok := requestBody["history_limit"].(float64); ok && v >= 1 && v <= maxHistoryLimit {
+		return int(v)
 	}
+	return defaultHistoryLimit
+}
 
-	// Create the HTTP request
-	req, err := http.NewRequest("POST", getLLMEndpoint(), bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+// historyFor looks up prior turns for an existing session; a brand-new
+// session has no history to fetch.
+func historyFor(sessionID string, isNewSession bool) []ChatMessage {
+	if isNewSession {
+		return nil
 	}
+	history, _ := sessionStore.Get(sessionID)
+	return history
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Set a timeout for the HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// cacheKeyFor computes the cache key for a chat turn, scoped to the session
+// once a conversation has history so one session's answer is never served to
+// another; stateless callers (no history yet) keep the plain message-only
+// key, which streamed and non-streamed requests must compute identically so
+// a first-turn answer from one path can be reused by the other.
+func cacheKeyFor(sessionID, message string, history []ChatMessage) string {
+	if len(history) > 0 {
+		return sessionID + "|" + message
 	}
+	return message
+}
 
-	// Send the request
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+// buildMessages assembles the full prompt sent upstream: the fixed system
+// prompt, then trimmed conversation history, then the new user message.
+func buildMessages(history []ChatMessage, userMessage string) []ChatMessage {
+	messages := make([]ChatMessage, 0, len(history)+2)
+	messages = append(messages, ChatMessage{Role: "system", Content: defaultSystemPrompt})
+	messages = append(messages, history...)
+	messages = append(messages, ChatMessage{Role: "user", Content: userMessage})
+	return messages
+}
+
+// wantsStream reports whether the caller asked for a streamed response,
+// either explicitly in the request body or via the Accept header.
+func wantsStream(requestBody map[string]interface{}, r *http.Request) bool {
+	if stream, ok := requestBody["stream"].(bool); ok && stream {
+		return true
 	}
-	defer resp.Body.Close()
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/event-stream") || strings.Contains(accept, "application/x-ndjson")
+}
 
-	// Read the response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+// handleChatStream relays the LLM's streamed output to the client as it
+// arrives, using SSE by default or NDJSON when the client asked for it.
+func handleChatStream(w http.ResponseWriter, r *http.Request, sessionID, message, cacheKey string, messages []ChatMessage) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
 	}
 
-	// Check if the status code is not 200 OK
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	ndjson := strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
 	}
+	w.Header().Set("X-Session-Id", sessionID)
 
-	// Parse the response
-	var chatResponse ChatResponse
-	err = json.Unmarshal(respBody, &chatResponse)
+	llmStart := time.Now()
+	full, err := llmProvider.ChatStream(r.Context(), messages, w, flusher, ndjson)
+	observeLLMCall(llmStart, err)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		logger.Printf("Error streaming from LLM API: %v", err)
+		if full == "" {
+			// Nothing was flushed yet; we can still report a clean error.
+			http.Error(w, "Failed to get response from LLM", http.StatusInternalServerError)
+		}
+		return
 	}
 
-	// Extract the assistant's message
-	if len(chatResponse.Choices) > 0 {
-		return strings.TrimSpace(chatResponse.Choices[0].Message.Content), nil
+	// Only a fully assembled stream is worth caching and persisting.
+	cache.Set(cacheKey, full, 5*time.Minute)
+	sessionStore.Append(sessionID, ChatMessage{Role: "user", Content: message}, ChatMessage{Role: "assistant", Content: full})
+}
+
+// handleGetSession returns the stored history for a conversation.
+func handleGetSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	history, found := sessionStore.Get(id)
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
 	}
 
-	return "", fmt.Errorf("no response choices returned from API")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": id,
+		"history":    history,
+	})
+}
+
+// handleDeleteSession resets a conversation, discarding its history.
+func handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	sessionStore.Delete(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// defaultPromptStarterLimit is used when the request omits "limit" or
+// supplies one outside [1, maxPromptStarterLimit].
+const (
+	defaultPromptStarterLimit = 4
+	maxPromptStarterLimit     = 10
+)
+
+// promptStarterLinePrefix strips numbering/bullets ("1.", "2)", "-", "*",
+// "•") so only the prompt text itself ends up in the result.
+var promptStarterLinePrefix = regexp.MustCompile(`^\s*(?:[0-9]+[.)]|[-*•])\s*`)
+
+// handlePromptStarters asks the configured LLM for a handful of example
+// questions a user could open the chat with, so the empty-chat state has
+// something to offer besides a blank input box.
+func handlePromptStarters(w http.ResponseWriter, r *http.Request) {
+	allowed, remaining, retryAfter := rateLimiter.Allow(clientKeyFor(r))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.999)))
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(retryAfter).Unix(), 10))
+		rateLimitedTotal.Inc()
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining)))
+
+	var body struct {
+		Topic string `json:"topic"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	limit := body.Limit
+	if limit < 1 || limit > maxPromptStarterLimit {
+		limit = defaultPromptStarterLimit
+	}
+
+	cacheKey := fmt.Sprintf("prompt-starters|%s|%d", body.Topic, limit)
+	if cached, found := cache.Get(cacheKey); found {
+		var starters []string
+		if err := json.Unmarshal([]byte(cached), &starters); err == nil {
+			cacheHitsTotal.Inc()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(starters)
+			return
+		}
+	}
+	cacheMissesTotal.Inc()
+
+	llmStart := time.Now()
+	response, err := llmProvider.Chat(r.Context(), promptStarterMessages(body.Topic, limit))
+	observeLLMCall(llmStart, err)
+	if err != nil {
+		logger.Printf("Error calling LLM API for prompt starters: %v", err)
+		http.Error(w, "Failed to get response from LLM", http.StatusInternalServerError)
+		return
+	}
+
+	starters := parsePromptStarters(response, limit)
+
+	if encoded, err := json.Marshal(starters); err == nil {
+		cache.Set(cacheKey, string(encoded), 10*time.Minute)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(starters)
+}
+
+// promptStarterMessages builds the prompt asking the model for `limit`
+// example questions about topic, or its own general capabilities when
+// topic is empty.
+func promptStarterMessages(topic string, limit int) []ChatMessage {
+	subject := "its own general capabilities"
+	if topic != "" {
+		subject = fmt.Sprintf("the topic %q", topic)
+	}
+
+	system := fmt.Sprintf(
+		"You generate example chat prompts. Reply with exactly %d short, self-contained example questions a user could ask about %s. "+
+			"Put one question per line, numbered (\"1. ...\"), and include no other commentary.",
+		limit, subject,
+	)
+
+	return []ChatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: "Generate the example prompts now."},
+	}
+}
+
+// parsePromptStarters extracts up to limit example questions from a
+// numbered or bulleted LLM response.
+func parsePromptStarters(response string, limit int) []string {
+	starters := make([]string, 0, limit)
+	for _, line := range strings.Split(response, "\n") {
+		line = promptStarterLinePrefix.ReplaceAllString(line, "")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		starters = append(starters, line)
+		if len(starters) == limit {
+			break
+		}
+	}
+	return starters
+}
+
+// Provider abstracts over the backend that actually talks to an LLM, so
+// hello-genai can point at any OpenAI-compatible endpoint, a native Ollama
+// server, or Anthropic's Messages API by flipping LLM_PROVIDER.
+type Provider interface {
+	Chat(ctx context.Context, messages []ChatMessage) (string, error)
+	ChatStream(ctx context.Context, messages []ChatMessage, w io.Writer, flusher http.Flusher, ndjson bool) (string, error)
+	ModelName() string
+}
+
+// observeLLMCall records an upstream LLM call's latency and outcome in the
+// hellogenai_llm_request_duration_seconds histogram.
+func observeLLMCall(start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	llmRequestDuration.WithLabelValues(config.LLMProvider, llmProvider.ModelName(), outcome).Observe(time.Since(start).Seconds())
+}
+
+// recordTokenUsage adds prompt/completion token counts to
+// hellogenai_llm_tokens_total when the upstream response reported usage.
+func recordTokenUsage(promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		llmTokensTotal.WithLabelValues("prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		llmTokensTotal.WithLabelValues("completion").Add(float64(completionTokens))
+	}
+}
+
+// newProvider builds the Provider selected by cfg.LLMProvider, falling back
+// to the OpenAI-compatible provider (the historical default) when unset or
+// unrecognized.
+func newProvider(cfg Configuration) Provider {
+	switch cfg.LLMProvider {
+	case "", "openai":
+		return &OpenAIProvider{BaseURL: cfg.LLMBaseURL, Model: cfg.LLMModelName}
+	case "ollama":
+		return &OllamaProvider{BaseURL: cfg.OllamaBaseURL, Model: cfg.OllamaModel}
+	case "anthropic":
+		return &AnthropicProvider{
+			BaseURL: cfg.AnthropicBaseURL,
+			APIKey:  cfg.AnthropicAPIKey,
+			Model:   cfg.AnthropicModel,
+			Version: cfg.AnthropicVersion,
+		}
+	default:
+		logger.Printf("WARNING: unknown LLM_PROVIDER=%s, falling back to openai", cfg.LLMProvider)
+		return &OpenAIProvider{BaseURL: cfg.LLMBaseURL, Model: cfg.LLMModelName}
+	}
+}
+
+// writeStreamChunk emits one piece of streamed content to the client, as an
+// SSE "data:" frame or as a line of NDJSON depending on ndjson.
+func writeStreamChunk(w io.Writer, content string, ndjson bool) {
+	payload := mustMarshal(map[string]string{"response": content})
+	if ndjson {
+		fmt.Fprintf(w, "%s\n", payload)
+	} else {
+		fmt.Fprintf(w, "data: %s\n\n", payload)
+	}
+}
+
+// mustMarshal marshals v to JSON, falling back to an empty object on error
+// (which cannot happen for the plain string maps this is used with).
+func mustMarshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// OpenAIProvider talks to any OpenAI-compatible /chat/completions endpoint
+// (this is also what Docker Model Runner and llama.cpp's server expose).
+type OpenAIProvider struct {
+	BaseURL string
+	Model   string
+}
+
+func (p *OpenAIProvider) ModelName() string { return p.Model }
+
+func (p *OpenAIProvider) endpoint() string { return p.BaseURL + "/chat/completions" }
+
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	chatRequest := ChatRequest{
+		Model:    p.Model,
+		Messages: messages,
+	}
+
+	requestBody, err := json.Marshal(chatRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var chatResponse ChatResponse
+	if err := json.Unmarshal(respBody, &chatResponse); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	recordTokenUsage(chatResponse.Usage.PromptTokens, chatResponse.Usage.CompletionTokens)
+
+	if len(chatResponse.Choices) > 0 {
+		return strings.TrimSpace(chatResponse.Choices[0].Message.Content), nil
+	}
+
+	return "", fmt.Errorf("no response choices returned from API")
+}
+
+// ChatStream opens a streaming completion upstream and relays each token to
+// w as it arrives, returning the fully assembled response so the caller can
+// cache it once the stream completes cleanly. The upstream call is aborted
+// if ctx is cancelled (e.g. the client disconnects).
+func (p *OpenAIProvider) ChatStream(ctx context.Context, messages []ChatMessage, w io.Writer, flusher http.Flusher, ndjson bool) (string, error) {
+	chatRequest := ChatRequest{
+		Model:    p.Model,
+		Messages: messages,
+		Stream:   true,
+	}
+
+	requestBody, err := json.Marshal(chatRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Timeout: 0, // streaming responses are bounded by ctx, not a fixed timeout
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return full.String(), ctx.Err()
+		default:
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			// Not every line is a data frame (e.g. SSE comments); skip it.
+			continue
+		}
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		content := chunk.Choices[0].Delta.Content
+		if content == "" {
+			continue
+		}
+		full.WriteString(content)
+		writeStreamChunk(w, content, ndjson)
+		flusher.Flush()
+	}
+
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return full.String(), nil
+}
+
+// OllamaProvider talks to a native Ollama server, whose /api/chat schema has
+// no "choices" wrapper and streams newline-delimited JSON rather than SSE.
+type OllamaProvider struct {
+	BaseURL string
+	Model   string
+}
+
+func (p *OllamaProvider) ModelName() string { return p.Model }
+
+func (p *OllamaProvider) endpoint() string { return p.BaseURL + "/api/chat" }
+
+type ollamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type ollamaChatResponse struct {
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	requestBody, err := json.Marshal(ollamaChatRequest{Model: p.Model, Messages: messages, Stream: false})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var chatResponse ollamaChatResponse
+	if err := json.Unmarshal(respBody, &chatResponse); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	recordTokenUsage(chatResponse.PromptEvalCount, chatResponse.EvalCount)
+
+	return strings.TrimSpace(chatResponse.Message.Content), nil
+}
+
+func (p *OllamaProvider) ChatStream(ctx context.Context, messages []ChatMessage, w io.Writer, flusher http.Flusher, ndjson bool) (string, error) {
+	requestBody, err := json.Marshal(ollamaChatRequest{Model: p.Model, Messages: messages, Stream: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 0}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return full.String(), ctx.Err()
+		default:
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue
+		}
+
+		if chunk.Message.Content != "" {
+			full.WriteString(chunk.Message.Content)
+			writeStreamChunk(w, chunk.Message.Content, ndjson)
+			flusher.Flush()
+		}
+
+		if chunk.Done {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return full.String(), nil
+}
+
+// AnthropicProvider talks to Anthropic's Messages API, which takes the
+// system prompt as a top-level field and authenticates via x-api-key.
+type AnthropicProvider struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Version string
+}
+
+// anthropicMaxTokens bounds how long a single reply may be; Anthropic
+// requires max_tokens on every request.
+const anthropicMaxTokens = 1024
+
+func (p *AnthropicProvider) ModelName() string { return p.Model }
+
+func (p *AnthropicProvider) endpoint() string { return p.BaseURL + "/v1/messages" }
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// splitSystemPrompt pulls out "system" role messages (Anthropic has no such
+// role in its messages array) and returns the rest as Anthropic messages.
+func splitSystemPrompt(messages []ChatMessage) (string, []anthropicMessage) {
+	var system []string
+	rest := make([]anthropicMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		rest = append(rest, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+	return strings.Join(system, "\n"), dropLeadingNonUser(rest)
+}
+
+// dropLeadingNonUser trims any leading messages that aren't from the user.
+// History trimming (char budget, an odd history_limit) can leave an
+// assistant turn at the front of the conversation, which Anthropic's
+// Messages API rejects outright since it requires the first message to be
+// from the user.
+func dropLeadingNonUser(messages []anthropicMessage) []anthropicMessage {
+	for len(messages) > 0 && messages[0].Role != "user" {
+		messages = messages[1:]
+	}
+	return messages
+}
+
+func (p *AnthropicProvider) newRequest(ctx context.Context, messages []ChatMessage, stream bool) (*http.Request, error) {
+	system, rest := splitSystemPrompt(messages)
+	body, err := json.Marshal(anthropicRequest{
+		Model:     p.Model,
+		System:    system,
+		Messages:  rest,
+		MaxTokens: anthropicMaxTokens,
+		Stream:    stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", p.Version)
+	return req, nil
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	req, err := p.newRequest(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var chatResponse anthropicResponse
+	if err := json.Unmarshal(respBody, &chatResponse); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	recordTokenUsage(chatResponse.Usage.InputTokens, chatResponse.Usage.OutputTokens)
+
+	var full strings.Builder
+	for _, block := range chatResponse.Content {
+		full.WriteString(block.Text)
+	}
+	return strings.TrimSpace(full.String()), nil
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+func (p *AnthropicProvider) ChatStream(ctx context.Context, messages []ChatMessage, w io.Writer, flusher http.Flusher, ndjson bool) (string, error) {
+	req, err := p.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: 0}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API returned status code %d: %s", resp.StatusCode, respBody)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return full.String(), ctx.Err()
+		default:
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+
+		if event.Type != "content_block_delta" || event.Delta.Text == "" {
+			continue
+		}
+		full.WriteString(event.Delta.Text)
+		writeStreamChunk(w, event.Delta.Text, ndjson)
+		flusher.Flush()
+	}
+
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return full.String(), nil
 }
 
 // handleSwaggerUI serves the Swagger UI for API documentation
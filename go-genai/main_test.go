@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeStreamProvider is a minimal Provider used to exercise handleChatStream
+// without making a real upstream call.
+type fakeStreamProvider struct{}
+
+func (fakeStreamProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return "fake reply", nil
+}
+
+func (fakeStreamProvider) ChatStream(ctx context.Context, messages []ChatMessage, w io.Writer, flusher http.Flusher, ndjson bool) (string, error) {
+	writeStreamChunk(w, "hello", ndjson)
+	flusher.Flush()
+	writeStreamChunk(w, " world", ndjson)
+	flusher.Flush()
+	return "hello world", nil
+}
+
+func (fakeStreamProvider) ModelName() string {
+	return "fake-model"
+}
+
+// TestChatAPIStreamingThroughMiddleware drives /api/chat with
+// Accept: text/event-stream through the real middleware chain and asserts
+// the response carries flushed "data:" frames rather than a 500 - a
+// regression test for statusRecorder not forwarding Flush().
+func TestChatAPIStreamingThroughMiddleware(t *testing.T) {
+	previousProvider := llmProvider
+	llmProvider = fakeStreamProvider{}
+	defer func() { llmProvider = previousProvider }()
+
+	router := mux.NewRouter()
+	router.Use(requestIDMiddleware)
+	router.Use(securityHeadersMiddleware)
+	router.Use(loggingMiddleware)
+	router.HandleFunc("/api/chat", handleChatAPI).Methods("POST")
+
+	body := strings.NewReader(`{"message": "hi"}`)
+	req := httptest.NewRequest("POST", "/api/chat", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !rec.Flushed {
+		t.Fatal("expected the response to have been flushed at least once")
+	}
+
+	got := rec.Body.String()
+	if !strings.Contains(got, "data: ") {
+		t.Fatalf("expected SSE data frames, got %q", got)
+	}
+	if !strings.Contains(got, "hello") || !strings.Contains(got, "world") {
+		t.Fatalf("expected both streamed chunks in body, got %q", got)
+	}
+
+	var lastChunk map[string]string
+	lines := strings.Split(strings.TrimSpace(got), "\n\n")
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[len(lines)-1], "data: ")), &lastChunk); err != nil {
+		t.Fatalf("failed to decode final SSE frame: %v", err)
+	}
+}